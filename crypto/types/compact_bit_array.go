@@ -0,0 +1,655 @@
+package types
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/bits"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// CompactBitArray is an implementation of a space efficient bit array.
+// This is used to ensure that the encoded data takes up a minimal amount
+// of space after amino or proto encoding.
+// This is not thread safe, and is not intended for concurrent usage.
+type CompactBitArray struct {
+	ExtraBitsStored uint32 `protobuf:"varint,1,opt,name=extra_bits_stored,json=extraBitsStored,proto3" json:"extra_bits_stored,omitempty"`
+	Elems           []byte `protobuf:"bytes,2,opt,name=elems,proto3" json:"elems,omitempty"`
+
+	// rankCum is a lazily built, opt-in rank/select index: rankCum[b] holds
+	// the cumulative popcount of all Elems bytes preceding block b (blocks
+	// are rankBlockBytes bytes wide), with rankCum[len(rankCum)-1] the total
+	// popcount. It is built on first use by NumTrueBitsBefore/Select and
+	// dropped by SetIndex, so a mutated array recomputes it on next query.
+	rankCum []uint32
+}
+
+// rankBlockBytes is the width, in Elems bytes, of one rankCum bucket.
+const rankBlockBytes = 8
+
+// NewCompactBitArray returns a new compact bit array.
+// It returns nil if the number of bits is zero or negative.
+func NewCompactBitArray(bits int) *CompactBitArray {
+	if bits <= 0 {
+		return nil
+	}
+
+	numElems := numByteSliceElems(bits)
+	if numElems < 0 || numElems > math.MaxInt32-4 {
+		// The used size of a CompactBitArray cannot overflow the capacity
+		// of its Elems slice.
+		return nil
+	}
+
+	return &CompactBitArray{
+		ExtraBitsStored: uint32(bits % 8),
+		Elems:           make([]byte, numElems),
+	}
+}
+
+// numByteSliceElems returns the number of bytes needed to store the given
+// number of bits.
+func numByteSliceElems(bits int) int {
+	return (bits + 7) / 8
+}
+
+// Count returns the number of bits in the bitarray.
+func (bA *CompactBitArray) Count() int {
+	if bA == nil || len(bA.Elems) == 0 {
+		return 0
+	}
+	if bA.ExtraBitsStored == 0 {
+		return len(bA.Elems) * 8
+	}
+	return (len(bA.Elems)-1)*8 + int(bA.ExtraBitsStored)
+}
+
+// GetIndex returns true if the bit at index i is set; returns false if the
+// index is out of range or the receiver is nil.
+func (bA *CompactBitArray) GetIndex(i int) bool {
+	if bA == nil {
+		return false
+	}
+	if i < 0 || i >= bA.Count() {
+		return false
+	}
+	return bA.Elems[i>>3]&(uint8(1)<<uint8(7-(i%8))) > 0
+}
+
+// SetIndex sets the bit at index i to v. It returns false (and does nothing)
+// if the index is out of range or the receiver is nil.
+func (bA *CompactBitArray) SetIndex(i int, v bool) bool {
+	if bA == nil {
+		return false
+	}
+	if i < 0 || i >= bA.Count() {
+		return false
+	}
+	if v {
+		bA.Elems[i>>3] |= uint8(1) << uint8(7-(i%8))
+	} else {
+		bA.Elems[i>>3] &= ^(uint8(1) << uint8(7-(i%8)))
+	}
+	bA.rankCum = nil
+	return true
+}
+
+// ensureRankIndex lazily (re)builds rankCum. It is a no-op once built; the
+// cache is cleared by SetIndex so the next rank/select query rebuilds it.
+func (bA *CompactBitArray) ensureRankIndex() {
+	if bA.rankCum != nil {
+		return
+	}
+	numBlocks := (len(bA.Elems) + rankBlockBytes - 1) / rankBlockBytes
+	cum := make([]uint32, numBlocks+1)
+	for b := 0; b < numBlocks; b++ {
+		start := b * rankBlockBytes
+		end := start + rankBlockBytes
+		if end > len(bA.Elems) {
+			end = len(bA.Elems)
+		}
+		count := cum[b]
+		for _, e := range bA.Elems[start:end] {
+			count += uint32(bits.OnesCount8(e))
+		}
+		cum[b+1] = count
+	}
+	bA.rankCum = cum
+}
+
+// NumTrueBitsBefore returns the number of bits set to true before the given
+// index, using the rankCum index to answer in time bounded by
+// rankBlockBytes rather than scanning every preceding bit.
+func (bA *CompactBitArray) NumTrueBitsBefore(index int) int {
+	if bA == nil {
+		return 0
+	}
+	max := bA.Count()
+	if index > max {
+		index = max
+	}
+	if index <= 0 {
+		return 0
+	}
+	bA.ensureRankIndex()
+
+	byteIdx := index / 8
+	block := byteIdx / rankBlockBytes
+	rank := int(bA.rankCum[block])
+	for j := block * rankBlockBytes; j < byteIdx; j++ {
+		rank += bits.OnesCount8(bA.Elems[j])
+	}
+	if rem := index % 8; rem > 0 {
+		mask := byte(0xff) << (8 - rem)
+		rank += bits.OnesCount8(bA.Elems[byteIdx] & mask)
+	}
+	return rank
+}
+
+// Rank is an alias for NumTrueBitsBefore, named to match the classic
+// succinct-data-structure rank/select operation pair.
+func (bA *CompactBitArray) Rank(i int) int {
+	return bA.NumTrueBitsBefore(i)
+}
+
+// Select returns the index of the k-th set bit (0-indexed, ascending order).
+// It returns -1 if bA is nil, k is negative, or bA has fewer than k+1 bits
+// set.
+func (bA *CompactBitArray) Select(k int) int {
+	if bA == nil || k < 0 {
+		return -1
+	}
+	bA.ensureRankIndex()
+	cum := bA.rankCum
+	numBlocks := len(cum) - 1
+	if numBlocks == 0 || uint32(k) >= cum[numBlocks] {
+		return -1
+	}
+
+	block := sort.Search(numBlocks, func(b int) bool { return cum[b+1] > uint32(k) })
+	remaining := k - int(cum[block])
+
+	start := block * rankBlockBytes
+	end := start + rankBlockBytes
+	if end > len(bA.Elems) {
+		end = len(bA.Elems)
+	}
+	for byteIdx := start; byteIdx < end; byteIdx++ {
+		e := bA.Elems[byteIdx]
+		c := bits.OnesCount8(e)
+		if remaining < c {
+			return byteIdx*8 + selectInByte(e, remaining)
+		}
+		remaining -= c
+	}
+	return -1
+}
+
+// selectByteTable[e][k] is the bit position (0 = MSB .. 7 = LSB) of the k-th
+// set bit in byte e, or -1 if e has fewer than k+1 bits set.
+var selectByteTable [256][8]int8
+
+func init() {
+	for e := 0; e < 256; e++ {
+		k := 0
+		for pos := 0; pos < 8; pos++ {
+			if byte(e)&(1<<uint(7-pos)) != 0 {
+				selectByteTable[e][k] = int8(pos)
+				k++
+			}
+		}
+		for ; k < 8; k++ {
+			selectByteTable[e][k] = -1
+		}
+	}
+}
+
+func selectInByte(e byte, k int) int {
+	return int(selectByteTable[e][k])
+}
+
+// PickRandom returns a uniformly chosen index among bA's set bits, using r
+// as the source of randomness, and false if bA is nil or has no bits set.
+// If the rankCum index is already built, a pick is just an O(1)-ish
+// Rank+Select. Otherwise, rather than paying to build the whole index for a
+// single pick, it reservoir-samples over Elems in one pass, using
+// bits.TrailingZeros8 to jump straight to each byte's set bits instead of
+// testing all 8.
+func (bA *CompactBitArray) PickRandom(r *rand.Rand) (int, bool) {
+	if bA == nil {
+		return 0, false
+	}
+	if bA.rankCum != nil {
+		popcount := bA.Rank(bA.Count())
+		if popcount == 0 {
+			return 0, false
+		}
+		idx := bA.Select(r.Intn(popcount))
+		if idx < 0 {
+			return 0, false
+		}
+		return idx, true
+	}
+
+	seen := 0
+	picked := -1
+	total := bA.Count()
+	for byteIdx, e := range bA.Elems {
+		base := byteIdx * 8
+		for e != 0 {
+			tz := bits.TrailingZeros8(e)
+			e &^= 1 << uint(tz)
+			idx := base + (7 - tz) // MSB-first bit ordering: bit i lives at position 7-(i%8)
+			if idx >= total {
+				continue
+			}
+			seen++
+			if r.Intn(seen) == 0 {
+				picked = idx
+			}
+		}
+	}
+	if picked < 0 {
+		return 0, false
+	}
+	return picked, true
+}
+
+// RangeTrueBits yields the index of every set bit in bA in ascending order,
+// without allocating a slice. It follows the Go 1.23 range-over-func shape,
+// so it can be used directly as `for i := range bA.RangeTrueBits { ... }`.
+// Zero bytes are skipped outright, and set bits within a nonzero byte are
+// peeled off MSB-first (matching GetIndex/SetIndex's bit ordering) via
+// bits.LeadingZeros8 rather than testing each of the 8 bits individually.
+func (bA *CompactBitArray) RangeTrueBits(yield func(i int) bool) {
+	if bA == nil {
+		return
+	}
+	total := bA.Count()
+	for byteIdx, e := range bA.Elems {
+		base := byteIdx * 8
+		for e != 0 {
+			lz := bits.LeadingZeros8(e)
+			idx := base + lz
+			if idx >= total {
+				return
+			}
+			if !yield(idx) {
+				return
+			}
+			e &^= 1 << uint(7-lz)
+		}
+	}
+}
+
+// Copy returns a copy of the provided bit array.
+func (bA *CompactBitArray) Copy() *CompactBitArray {
+	if bA == nil {
+		return nil
+	}
+	c := make([]byte, len(bA.Elems))
+	copy(c, bA.Elems)
+	return &CompactBitArray{
+		ExtraBitsStored: bA.ExtraBitsStored,
+		Elems:           c,
+	}
+}
+
+// Equal checks if both bit arrays are equal. Two nil values are considered
+// equal, but a nil and a non-nil value are not.
+func (bA *CompactBitArray) Equal(other *CompactBitArray) bool {
+	if bA == other {
+		return true
+	}
+	if bA == nil || other == nil {
+		return false
+	}
+	return bA.ExtraBitsStored == other.ExtraBitsStored && bytes.Equal(bA.Elems, other.Elems)
+}
+
+// String returns a string representation of CompactBitArray: 'x' for the
+// bits set, and '_' for the bits not set, with no separators.
+func (bA *CompactBitArray) String() string {
+	if bA == nil {
+		return "nil-BitArray"
+	}
+	var sb strings.Builder
+	for i := 0; i < bA.Count(); i++ {
+		if bA.GetIndex(i) {
+			sb.WriteByte('x')
+		} else {
+			sb.WriteByte('_')
+		}
+	}
+	return sb.String()
+}
+
+// MarshalJSON implements the json.Marshaler interface, marshaling the bit
+// array as a string of 'x' (true) and '_' (false), e.g. "xx__x".
+func (bA *CompactBitArray) MarshalJSON() ([]byte, error) {
+	if bA == nil {
+		return []byte("null"), nil
+	}
+	bits := make([]byte, bA.Count())
+	for i := 0; i < bA.Count(); i++ {
+		if bA.GetIndex(i) {
+			bits[i] = 'x'
+		} else {
+			bits[i] = '_'
+		}
+	}
+	return json.Marshal(string(bits))
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface, reversing
+// MarshalJSON's "xx__x"-style encoding.
+func (bA *CompactBitArray) UnmarshalJSON(bz []byte) error {
+	b := string(bz)
+	if b == "null" {
+		// no need to update anything
+		return nil
+	}
+
+	// the `"` are ignored here
+	bits := len(b) - 2
+	bA2 := NewCompactBitArray(bits)
+	for i := 0; i < bits; i++ {
+		if b[i+1] == 'x' {
+			bA2.SetIndex(i, true)
+		}
+	}
+	*bA = *bA2
+	return nil
+}
+
+// trailingMask returns a mask for the final byte of Elems that keeps only
+// the bits actually stored there, so that a word-at-a-time bitwise op never
+// leaves dirty high bits behind in a partial last byte.
+func trailingMask(extraBitsStored uint32) byte {
+	valid := extraBitsStored
+	if valid == 0 {
+		valid = 8
+	}
+	return byte(0xff) << (8 - valid)
+}
+
+// cleanCopy returns a copy of bA with its trailing byte masked to its own
+// trailingMask(ExtraBitsStored), guaranteeing no dirty padding bits beyond
+// Count() survive into the copy. A CompactBitArray constructed outside this
+// package's own setters and decoders (e.g. a raw struct literal) is not
+// guaranteed to have clean padding.
+func (bA *CompactBitArray) cleanCopy() *CompactBitArray {
+	if bA == nil {
+		return nil
+	}
+	c := bA.Copy()
+	if len(c.Elems) > 0 {
+		c.Elems[len(c.Elems)-1] &= trailingMask(c.ExtraBitsStored)
+	}
+	return c
+}
+
+// combine builds a new CompactBitArray of resBits bits by applying op
+// byte-at-a-time over bA and o's Elems, treating any byte beyond an
+// operand's own length as zero. Each operand's own trailing byte is masked
+// to its own trailingMask before use, so dirty padding bits past an
+// operand's Count() (which combine's own result-length growth, e.g. Or,
+// can otherwise pull into newly in-range positions) never leak into the
+// result; the result's own trailing byte is masked the same way afterward.
+func (bA *CompactBitArray) combine(o *CompactBitArray, resBits int, op func(a, b byte) byte) *CompactBitArray {
+	res := NewCompactBitArray(resBits)
+	if res == nil {
+		return res
+	}
+	aLast := len(bA.Elems) - 1
+	bLast := len(o.Elems) - 1
+	for i := range res.Elems {
+		var a, b byte
+		if i < len(bA.Elems) {
+			a = bA.Elems[i]
+			if i == aLast {
+				a &= trailingMask(bA.ExtraBitsStored)
+			}
+		}
+		if i < len(o.Elems) {
+			b = o.Elems[i]
+			if i == bLast {
+				b &= trailingMask(o.ExtraBitsStored)
+			}
+		}
+		res.Elems[i] = op(a, b)
+	}
+	res.Elems[len(res.Elems)-1] &= trailingMask(res.ExtraBitsStored)
+	return res
+}
+
+// And returns the bitwise AND of bA and o. The result has
+// Size = min(bA.Count(), o.Count()), with the longer operand's extra high
+// bits discarded. And with a nil receiver or a nil argument returns nil.
+func (bA *CompactBitArray) And(o *CompactBitArray) *CompactBitArray {
+	if bA == nil || o == nil {
+		return nil
+	}
+	return bA.combine(o, min(bA.Count(), o.Count()), func(a, b byte) byte { return a & b })
+}
+
+// Or returns the bitwise OR of bA and o. The result has
+// Size = max(bA.Count(), o.Count()), with the shorter operand treated as
+// zero-padded in its missing high bits. Or with a nil receiver or a nil
+// argument returns a copy of the other (non-nil) operand.
+func (bA *CompactBitArray) Or(o *CompactBitArray) *CompactBitArray {
+	if bA == nil {
+		return o.cleanCopy()
+	}
+	if o == nil {
+		return bA.cleanCopy()
+	}
+	return bA.combine(o, max(bA.Count(), o.Count()), func(a, b byte) byte { return a | b })
+}
+
+// Xor returns the bitwise XOR of bA and o. The result has
+// Size = min(bA.Count(), o.Count()), with the longer operand's extra high
+// bits discarded. Xor with a nil receiver or a nil argument returns nil.
+func (bA *CompactBitArray) Xor(o *CompactBitArray) *CompactBitArray {
+	if bA == nil || o == nil {
+		return nil
+	}
+	return bA.combine(o, min(bA.Count(), o.Count()), func(a, b byte) byte { return a ^ b })
+}
+
+// Sub subtracts o from bA, i.e. it clears every bit in bA that is also set
+// in o ("bA AND NOT o"). Useful for diffing an "expected" signer set against
+// a "received" one. The result has Size = min(bA.Count(), o.Count()), with
+// the longer operand's extra high bits discarded. Sub with a nil receiver or
+// a nil argument returns nil.
+func (bA *CompactBitArray) Sub(o *CompactBitArray) *CompactBitArray {
+	if bA == nil || o == nil {
+		return nil
+	}
+	return bA.combine(o, min(bA.Count(), o.Count()), func(a, b byte) byte { return a &^ b })
+}
+
+// Not returns the bitwise complement of bA, keeping the same Size. Not on a
+// nil receiver returns nil.
+func (bA *CompactBitArray) Not() *CompactBitArray {
+	if bA == nil {
+		return nil
+	}
+	res := bA.Copy()
+	for i := range res.Elems {
+		res.Elems[i] = ^res.Elems[i]
+	}
+	if len(res.Elems) > 0 {
+		res.Elems[len(res.Elems)-1] &= trailingMask(res.ExtraBitsStored)
+	}
+	return res
+}
+
+// nullBytes is the sentinel CompactMarshal/Marshal emit for a nil
+// *CompactBitArray, and the only value its decoders accept back into a nil
+// or zero-value result.
+var nullBytes = []byte("null")
+
+// sovCompactBitArray returns the number of bytes needed to varint-encode x,
+// following the gogoproto-generated "sov" (size-of-varint) naming.
+func sovCompactBitArray(x uint64) (n int) {
+	for {
+		n++
+		x >>= 7
+		if x == 0 {
+			break
+		}
+	}
+	return n
+}
+
+// encodeVarintCompactBitArray writes v as a varint ending at offset in dAtA,
+// returning the offset of the first byte written — the same backwards
+// pattern gogoproto generates for MarshalToSizedBuffer.
+func encodeVarintCompactBitArray(dAtA []byte, offset int, v uint64) int {
+	offset -= sovCompactBitArray(v)
+	base := offset
+	for v >= 1<<7 {
+		dAtA[offset] = uint8(v&0x7f | 0x80)
+		v >>= 7
+		offset++
+	}
+	dAtA[offset] = uint8(v)
+	return base
+}
+
+// CompactMarshal is a space efficient encoding for CompactBitArray.
+// It is not amino compatible.
+func (bA *CompactBitArray) CompactMarshal() []byte {
+	bz, _ := bA.Marshal()
+	return bz
+}
+
+// CompactUnmarshal reverses the CompactMarshal function.
+func CompactUnmarshal(bz []byte) (*CompactBitArray, error) {
+	if bytes.Equal(bz, nullBytes) {
+		return nil, nil
+	}
+	bA := new(CompactBitArray)
+	if err := bA.UnmarshalMerge(bz); err != nil {
+		return nil, err
+	}
+	return bA, nil
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface by
+// wrapping CompactMarshal's wire format.
+func (bA *CompactBitArray) MarshalBinary() ([]byte, error) {
+	return bA.Marshal()
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface by
+// wrapping UnmarshalMerge. The receiver must be non-nil and addressable,
+// e.g. new(CompactBitArray).
+func (bA *CompactBitArray) UnmarshalBinary(data []byte) error {
+	return bA.UnmarshalMerge(data)
+}
+
+// Marshal implements the gogoproto Marshaler interface, so CompactBitArray
+// can be used as a (gogoproto.customtype) field directly in .proto messages
+// instead of going through an intermediate bytes field. The wire format is
+// exactly CompactMarshal's.
+func (bA *CompactBitArray) Marshal() ([]byte, error) {
+	data := make([]byte, bA.Size())
+	n, err := bA.MarshalToSizedBuffer(data)
+	if err != nil {
+		return nil, err
+	}
+	return data[len(data)-n:], nil
+}
+
+// MarshalTo implements the gogoproto Marshaler interface.
+func (bA *CompactBitArray) MarshalTo(data []byte) (int, error) {
+	size := bA.Size()
+	return bA.MarshalToSizedBuffer(data[:size])
+}
+
+// MarshalToSizedBuffer implements the gogoproto Marshaler interface used by
+// generated nested-message marshalers: it fills data from the back forward,
+// so nested messages can be encoded without an intermediate allocation.
+func (bA *CompactBitArray) MarshalToSizedBuffer(data []byte) (int, error) {
+	i := len(data)
+	if bA == nil {
+		i -= len(nullBytes)
+		copy(data[i:], nullBytes)
+		return len(data) - i, nil
+	}
+	i -= len(bA.Elems)
+	copy(data[i:], bA.Elems)
+	i = encodeVarintCompactBitArray(data, i, uint64(bA.Count()))
+	return len(data) - i, nil
+}
+
+// Size implements the gogoproto Marshaler interface.
+func (bA *CompactBitArray) Size() int {
+	if bA == nil {
+		return len(nullBytes)
+	}
+	return sovCompactBitArray(uint64(bA.Count())) + len(bA.Elems)
+}
+
+// Unmarshal implements the gogoproto Unmarshaler interface. The wire format is
+// exactly CompactMarshal/CompactUnmarshal's.
+func (bA *CompactBitArray) Unmarshal(data []byte) error {
+	return bA.UnmarshalMerge(data)
+}
+
+// UnmarshalMerge decodes data produced by CompactMarshal/Marshal into bA. It
+// reuses bA.Elems's backing array when its capacity is already large enough
+// instead of always allocating a fresh one, mirroring the "Merge" proto
+// unmarshalers generated for nested message fields. An encoded size of 0 (what
+// Marshal emits for an empty, non-nil CompactBitArray) is rejected as
+// invalid; only nullBytes decodes to an empty result.
+func (bA *CompactBitArray) UnmarshalMerge(data []byte) error {
+	if bytes.Equal(data, nullBytes) {
+		*bA = CompactBitArray{}
+		return nil
+	}
+
+	size, n := binary.Uvarint(data)
+	if n < 0 {
+		return fmt.Errorf("n=%d is out of range of len(bz)=%d", n, len(data))
+	}
+
+	// size is attacker-controlled wire data: reject before converting to
+	// int, since a size above math.MaxInt64 wraps to a small/negative int
+	// (Go's truncating conversion), which would otherwise slip past the
+	// numBytes checks below with a bogus small numBytes value. This is the
+	// same bound NewCompactBitArray enforces via its own bits<=0 check.
+	if size == 0 || size > math.MaxInt64 {
+		return fmt.Errorf("invalid size %d for CompactBitArray", size)
+	}
+	numBytes := numByteSliceElems(int(size))
+	if numBytes < 0 || numBytes > math.MaxInt32-4 {
+		return fmt.Errorf("invalid size %d for CompactBitArray", size)
+	}
+	if len(data[n:]) < numBytes {
+		return fmt.Errorf("not enough bytes (%d) to represent size (%d) bytes", len(data[n:]), numBytes)
+	}
+
+	if cap(bA.Elems) >= numBytes {
+		bA.Elems = bA.Elems[:numBytes]
+	} else {
+		bA.Elems = make([]byte, numBytes)
+	}
+	copy(bA.Elems, data[n:n+numBytes])
+	bA.ExtraBitsStored = uint32(size % 8)
+	// Wire bytes are untrusted: the trailing byte's unused high-order bits
+	// (beyond ExtraBitsStored) aren't guaranteed to be zero, and leaving
+	// them dirty would make this decode observably different from decoding
+	// the same bits via UnmarshalJSON, and from a freshly built
+	// CompactBitArray — e.g. And/Or/Xor/Sub's zero-padding assumption for a
+	// shorter operand. Mask them off.
+	bA.Elems[len(bA.Elems)-1] &= trailingMask(bA.ExtraBitsStored)
+	bA.rankCum = nil
+	return nil
+}
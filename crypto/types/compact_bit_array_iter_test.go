@@ -0,0 +1,130 @@
+package types
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompactBitArrayRangeTrueBits(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	bA, _ := randCompactBitArray(150)
+	var want []int
+	for i := 0; i < 150; i++ {
+		v := r.Intn(3) == 0
+		bA.SetIndex(i, v)
+		if v {
+			want = append(want, i)
+		}
+	}
+
+	var got []int
+	bA.RangeTrueBits(func(i int) bool {
+		got = append(got, i)
+		return true
+	})
+
+	require.Equal(t, want, got)
+	require.Equal(t, bA.NumTrueBitsBefore(bA.Count()), len(got))
+}
+
+func TestCompactBitArrayRangeTrueBitsStopsEarly(t *testing.T) {
+	bA := NewCompactBitArray(20)
+	for _, i := range []int{1, 5, 10, 19} {
+		bA.SetIndex(i, true)
+	}
+
+	var got []int
+	bA.RangeTrueBits(func(i int) bool {
+		got = append(got, i)
+		return len(got) < 2
+	})
+	require.Equal(t, []int{1, 5}, got)
+}
+
+func TestCompactBitArrayRangeTrueBitsNilAndEmpty(t *testing.T) {
+	called := false
+	(*CompactBitArray)(nil).RangeTrueBits(func(int) bool {
+		called = true
+		return true
+	})
+	require.False(t, called)
+
+	new(CompactBitArray).RangeTrueBits(func(int) bool {
+		called = true
+		return true
+	})
+	require.False(t, called)
+}
+
+func TestCompactBitArrayPickRandomNilAndEmpty(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	_, ok := (*CompactBitArray)(nil).PickRandom(r)
+	require.False(t, ok)
+
+	empty := NewCompactBitArray(10)
+	_, ok = empty.PickRandom(r)
+	require.False(t, ok)
+}
+
+func TestCompactBitArrayPickRandomIsUniform(t *testing.T) {
+	r := rand.New(rand.NewSource(99))
+	bA := NewCompactBitArray(8)
+	for _, i := range []int{0, 2, 4, 6} {
+		bA.SetIndex(i, true)
+	}
+
+	counts := make(map[int]int)
+	const trials = 40000
+	for i := 0; i < trials; i++ {
+		idx, ok := bA.PickRandom(r)
+		require.True(t, ok)
+		require.True(t, bA.GetIndex(idx))
+		counts[idx]++
+	}
+
+	require.Len(t, counts, 4)
+	want := float64(trials) / 4
+	for idx, c := range counts {
+		diff := float64(c) - want
+		if diff < 0 {
+			diff = -diff
+		}
+		require.Less(t, diff/want, 0.1, "index %d picked %d times, want ~%.0f", idx, c, want)
+	}
+}
+
+// TestCompactBitArrayPickRandomUsesRankIndexWhenBuilt exercises the other
+// half of PickRandom: once rankCum has already been built by an earlier
+// Rank/Select call, PickRandom should go through Select instead of the
+// reservoir-sampling fallback, and still be uniform and correct.
+func TestCompactBitArrayPickRandomUsesRankIndexWhenBuilt(t *testing.T) {
+	r := rand.New(rand.NewSource(7))
+	bA := NewCompactBitArray(8)
+	for _, i := range []int{1, 3, 5, 7} {
+		bA.SetIndex(i, true)
+	}
+	bA.Rank(bA.Count()) // force-build rankCum before PickRandom ever runs
+	require.NotNil(t, bA.rankCum)
+
+	counts := make(map[int]int)
+	const trials = 40000
+	for i := 0; i < trials; i++ {
+		idx, ok := bA.PickRandom(r)
+		require.True(t, ok)
+		require.True(t, bA.GetIndex(idx))
+		counts[idx]++
+	}
+
+	require.Len(t, counts, 4)
+	want := float64(trials) / 4
+	for idx, c := range counts {
+		diff := float64(c) - want
+		if diff < 0 {
+			diff = -diff
+		}
+		require.Less(t, diff/want, 0.1, "index %d picked %d times, want ~%.0f", idx, c, want)
+	}
+}
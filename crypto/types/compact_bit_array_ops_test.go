@@ -0,0 +1,133 @@
+package types
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// slowBitwise computes op bit-by-bit via GetIndex/SetIndex as a reference
+// implementation to cross-check the word-at-a-time And/Or/Xor/Sub against.
+func slowBitwise(a, b *CompactBitArray, resBits int, op func(x, y bool) bool) *CompactBitArray {
+	res := NewCompactBitArray(resBits)
+	if res == nil {
+		return res
+	}
+	for i := 0; i < resBits; i++ {
+		res.SetIndex(i, op(a.GetIndex(i), b.GetIndex(i)))
+	}
+	return res
+}
+
+func TestCompactBitArrayAndOrXorSub(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+
+	testCases := []struct {
+		name       string
+		aBits      int
+		bBits      int
+		op         func(x, y bool) bool
+		resultBits func(a, b int) int
+		run        func(a, b *CompactBitArray) *CompactBitArray
+	}{
+		{
+			name:       "And",
+			aBits:      37,
+			bBits:      50,
+			op:         func(x, y bool) bool { return x && y },
+			resultBits: func(a, b int) int { return min(a, b) },
+			run:        func(a, b *CompactBitArray) *CompactBitArray { return a.And(b) },
+		},
+		{
+			name:       "Or",
+			aBits:      50,
+			bBits:      37,
+			op:         func(x, y bool) bool { return x || y },
+			resultBits: func(a, b int) int { return max(a, b) },
+			run:        func(a, b *CompactBitArray) *CompactBitArray { return a.Or(b) },
+		},
+		{
+			name:       "Xor",
+			aBits:      9,
+			bBits:      16,
+			op:         func(x, y bool) bool { return x != y },
+			resultBits: func(a, b int) int { return min(a, b) },
+			run:        func(a, b *CompactBitArray) *CompactBitArray { return a.Xor(b) },
+		},
+		{
+			name:       "Sub",
+			aBits:      16,
+			bBits:      9,
+			op:         func(x, y bool) bool { return x && !y },
+			resultBits: func(a, b int) int { return min(a, b) },
+			run:        func(a, b *CompactBitArray) *CompactBitArray { return a.Sub(b) },
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			a, _ := randCompactBitArray(tc.aBits)
+			b, _ := randCompactBitArray(tc.bBits)
+			for i := 0; i < tc.aBits; i++ {
+				a.SetIndex(i, r.Intn(2) == 0)
+			}
+			for i := 0; i < tc.bBits; i++ {
+				b.SetIndex(i, r.Intn(2) == 0)
+			}
+
+			got := tc.run(a, b)
+			want := slowBitwise(a, b, tc.resultBits(tc.aBits, tc.bBits), tc.op)
+			require.True(t, want.Equal(got), "got %s, want %s", got, want)
+		})
+	}
+}
+
+func TestCompactBitArrayNot(t *testing.T) {
+	bA, _ := randCompactBitArray(21)
+	got := bA.Not()
+	require.Equal(t, bA.Count(), got.Count())
+	for i := 0; i < bA.Count(); i++ {
+		require.Equal(t, !bA.GetIndex(i), got.GetIndex(i))
+	}
+	// double negation is the identity
+	require.True(t, bA.Equal(got.Not()))
+}
+
+// TestCompactBitArrayOrMasksDirtyOperandPadding guards against a bug where
+// combine() copied an operand's trailing byte verbatim: if that byte has
+// garbage bits set past the operand's own ExtraBitsStored (as can happen
+// for a CompactBitArray built outside this package's setters, e.g. directly
+// from wire bytes), OR-ing it with a longer, all-zero operand used to leak
+// those phantom bits into the newly in-range result positions.
+func TestCompactBitArrayOrMasksDirtyOperandPadding(t *testing.T) {
+	dirty := &CompactBitArray{ExtraBitsStored: 5, Elems: []byte{0b00000111}}
+	empty := NewCompactBitArray(20)
+
+	got := dirty.Or(empty)
+	require.Equal(t, 20, got.Count())
+	for i := 0; i < got.Count(); i++ {
+		require.False(t, got.GetIndex(i), "phantom bit leaked in at index %d", i)
+	}
+
+	got = empty.Or(dirty)
+	for i := 0; i < got.Count(); i++ {
+		require.False(t, got.GetIndex(i), "phantom bit leaked in at index %d", i)
+	}
+}
+
+func TestCompactBitArrayBitwiseNilSemantics(t *testing.T) {
+	bA, _ := randCompactBitArray(5)
+
+	require.Nil(t, bA.And(nil))
+	require.Nil(t, (*CompactBitArray)(nil).And(bA))
+	require.Nil(t, (*CompactBitArray)(nil).And(nil))
+
+	require.True(t, bA.Equal(bA.Or(nil)))
+	require.True(t, bA.Equal((*CompactBitArray)(nil).Or(bA)))
+	require.Nil(t, (*CompactBitArray)(nil).Or(nil))
+
+	require.Nil(t, bA.Xor(nil))
+	require.Nil(t, bA.Sub(nil))
+	require.Nil(t, (*CompactBitArray)(nil).Not())
+}
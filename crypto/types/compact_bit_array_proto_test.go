@@ -0,0 +1,157 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompactBitArrayBinaryMarshalerRoundTrip(t *testing.T) {
+	bA, _ := randCompactBitArray(37)
+
+	bz, err := bA.MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t, bA.CompactMarshal(), bz)
+
+	got := new(CompactBitArray)
+	require.NoError(t, got.UnmarshalBinary(bz))
+	require.True(t, bA.Equal(got))
+}
+
+func TestCompactBitArrayProtoCustomTypeRoundTrip(t *testing.T) {
+	bA, _ := randCompactBitArray(101)
+
+	bz, err := bA.Marshal()
+	require.NoError(t, err)
+	require.Equal(t, bA.CompactMarshal(), bz)
+	require.Equal(t, bA.Size(), len(bz))
+
+	buf := make([]byte, bA.Size())
+	n, err := bA.MarshalTo(buf)
+	require.NoError(t, err)
+	require.Equal(t, bz, buf[:n])
+
+	sized := make([]byte, bA.Size())
+	n, err = bA.MarshalToSizedBuffer(sized)
+	require.NoError(t, err)
+	require.Equal(t, bz, sized[len(sized)-n:])
+
+	got := new(CompactBitArray)
+	require.NoError(t, got.Unmarshal(bz))
+	require.True(t, bA.Equal(got))
+}
+
+// TestCompactBitArrayUnmarshalMergeMasksDirtyTrailingBits guards against a
+// bug where UnmarshalMerge copied the wire bytes' trailing byte verbatim:
+// since those bytes are untrusted input, a dirty padding bit beyond
+// ExtraBitsStored used to survive the decode and make it observably
+// different from the same bits decoded via UnmarshalJSON.
+func TestCompactBitArrayUnmarshalMergeMasksDirtyTrailingBits(t *testing.T) {
+	// varint(5) (ExtraBitsStored=5, only the top 5 bits are meaningful)
+	// followed by a trailing byte with every bit set.
+	wire := []byte{5, 0xff}
+
+	got := new(CompactBitArray)
+	require.NoError(t, got.UnmarshalMerge(wire))
+	require.Equal(t, byte(0xf8), got.Elems[0], "unused padding bits must be masked off on decode")
+
+	bz, err := json.Marshal(got)
+	require.NoError(t, err)
+	var viaJSON *CompactBitArray
+	require.NoError(t, json.Unmarshal(bz, &viaJSON))
+	require.True(t, got.Equal(viaJSON))
+}
+
+// TestCompactBitArrayUnmarshalMergeRejectsOverflowingSize guards against a
+// panic where a varint-encoded size near math.MaxUint64 wrapped to a small
+// negative int on conversion, made numByteSliceElems return 0 via Go's
+// truncating division, and slipped past the size checks only to panic
+// indexing Elems[-1] when masking the (nonexistent) trailing byte. All
+// three public decode entry points funnel into UnmarshalMerge, so all three
+// must reject the input cleanly rather than panicking.
+func TestCompactBitArrayUnmarshalMergeRejectsOverflowingSize(t *testing.T) {
+	// varint(math.MaxUint64) = 0xff x9, 0x01
+	wire := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}
+
+	require.NotPanics(t, func() {
+		_, err := CompactUnmarshal(wire)
+		require.Error(t, err)
+	})
+	require.NotPanics(t, func() {
+		require.Error(t, new(CompactBitArray).UnmarshalBinary(wire))
+	})
+	require.NotPanics(t, func() {
+		require.Error(t, new(CompactBitArray).Unmarshal(wire))
+	})
+}
+
+func TestCompactBitArrayUnmarshalMergeReusesElems(t *testing.T) {
+	bA, _ := randCompactBitArray(40)
+	bz := bA.CompactMarshal()
+
+	backing := make([]byte, 0, 64)
+	full := backing[:cap(backing)]
+
+	got := &CompactBitArray{Elems: backing}
+	require.NoError(t, got.UnmarshalMerge(bz))
+	require.True(t, bA.Equal(got))
+	require.Same(t, &full[0], &got.Elems[0], "should reuse the existing backing array, not reallocate")
+}
+
+func TestCompactBitArrayProtoRejectsHostileInputLikeCompactUnmarshal(t *testing.T) {
+	malicious := []byte{0xd7, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01, 0x24, 0x28}
+
+	_, compactErr := CompactUnmarshal(malicious)
+	require.Error(t, compactErr)
+
+	protoErr := new(CompactBitArray).Unmarshal(malicious)
+	require.Error(t, protoErr)
+	require.Equal(t, compactErr.Error(), protoErr.Error())
+
+	binErr := new(CompactBitArray).UnmarshalBinary(malicious)
+	require.Error(t, binErr)
+	require.Equal(t, compactErr.Error(), binErr.Error())
+}
+
+// FuzzCompactBitArrayRoundTrip checks that CompactUnmarshal, the gogoproto
+// customtype Unmarshal, and UnmarshalBinary either all reject a given input,
+// or all decode it to the same bit array, and that the decoded array
+// survives a JSON round trip unchanged.
+func FuzzCompactBitArrayRoundTrip(f *testing.F) {
+	bA, _ := randCompactBitArray(53)
+	f.Add(bA.CompactMarshal())
+	f.Add(nullBytes)
+	f.Add([]byte{0xd7, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01, 0x24, 0x28})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		compactBA, compactErr := CompactUnmarshal(data)
+
+		protoBA := new(CompactBitArray)
+		protoErr := protoBA.Unmarshal(append([]byte(nil), data...))
+
+		binBA := new(CompactBitArray)
+		binErr := binBA.UnmarshalBinary(append([]byte(nil), data...))
+
+		require.Equal(t, compactErr == nil, protoErr == nil)
+		require.Equal(t, compactErr == nil, binErr == nil)
+		if compactErr != nil {
+			return
+		}
+
+		if compactBA == nil {
+			require.Equal(t, 0, protoBA.Count())
+			require.Equal(t, 0, binBA.Count())
+			return
+		}
+
+		require.True(t, compactBA.Equal(protoBA))
+		require.True(t, compactBA.Equal(binBA))
+
+		bz, err := json.Marshal(compactBA)
+		require.NoError(t, err)
+		var jsonBA *CompactBitArray
+		require.NoError(t, json.Unmarshal(bz, &jsonBA))
+		require.True(t, compactBA.Equal(jsonBA))
+	})
+}
@@ -0,0 +1,93 @@
+package types
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// numTrueBitsBeforeNaive is the pre-index linear scan, kept here as a
+// reference to test and benchmark the rankCum-backed NumTrueBitsBefore against.
+func numTrueBitsBeforeNaive(bA *CompactBitArray, index int) int {
+	max := bA.Count()
+	if index > max {
+		index = max
+	}
+	onesCount := 0
+	for i := 0; i < index; i++ {
+		if bA.GetIndex(i) {
+			onesCount++
+		}
+	}
+	return onesCount
+}
+
+func TestCompactBitArrayRankMatchesNaive(t *testing.T) {
+	r := rand.New(rand.NewSource(7))
+	for _, bits := range []int{1, 7, 8, 9, 63, 100, 1000} {
+		bA, _ := randCompactBitArray(bits)
+		for i := 0; i < bits; i++ {
+			bA.SetIndex(i, r.Intn(2) == 0)
+		}
+		for _, idx := range []int{-1, 0, 1, bits / 2, bits - 1, bits, bits + 10} {
+			require.Equal(t, numTrueBitsBeforeNaive(bA, idx), bA.NumTrueBitsBefore(idx), "bits=%d idx=%d", bits, idx)
+			require.Equal(t, bA.NumTrueBitsBefore(idx), bA.Rank(idx), "Rank must alias NumTrueBitsBefore")
+		}
+	}
+}
+
+func TestCompactBitArrayRankIndexInvalidatedBySetIndex(t *testing.T) {
+	bA := NewCompactBitArray(100)
+	for i := 0; i < 100; i++ {
+		bA.SetIndex(i, i%3 == 0)
+	}
+	before := bA.NumTrueBitsBefore(100) // builds and caches rankCum
+
+	bA.SetIndex(1, true) // previously false; must invalidate the cache
+	after := bA.NumTrueBitsBefore(100)
+	require.Equal(t, before+1, after)
+}
+
+func TestCompactBitArraySelect(t *testing.T) {
+	r := rand.New(rand.NewSource(11))
+	for _, bits := range []int{1, 8, 9, 100, 777} {
+		bA, _ := randCompactBitArray(bits)
+		var setIdx []int
+		for i := 0; i < bits; i++ {
+			v := r.Intn(2) == 0
+			bA.SetIndex(i, v)
+			if v {
+				setIdx = append(setIdx, i)
+			}
+		}
+		for k, want := range setIdx {
+			require.Equal(t, want, bA.Select(k), "bits=%d k=%d", bits, k)
+		}
+		require.Equal(t, -1, bA.Select(len(setIdx)))
+		require.Equal(t, -1, bA.Select(-1))
+	}
+
+	require.Equal(t, -1, (*CompactBitArray)(nil).Select(0))
+	require.Equal(t, -1, new(CompactBitArray).Select(0))
+}
+
+func BenchmarkNumTrueBitsBeforeSizes(b *testing.B) {
+	for _, size := range []int{100, 1000, 10000} {
+		ba, _ := randCompactBitArray(size)
+		idx := size - 1
+
+		b.Run("naive", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				numTrueBitsBeforeNaive(ba, idx)
+			}
+		})
+		b.Run("indexed", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				ba.NumTrueBitsBefore(idx)
+			}
+		})
+	}
+}